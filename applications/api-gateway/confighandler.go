@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	configReloadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_reloads_total",
+			Help: "Total number of successful config reloads",
+		},
+		[]string{"source"},
+	)
+
+	configReloadErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_reload_errors_total",
+			Help: "Total number of failed config reload attempts",
+		},
+		[]string{"source"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(configReloadsTotal)
+	prometheus.MustRegister(configReloadErrorsTotal)
+}
+
+const configRedisKey = "gateway:config"
+const configUpdateChannel = "gateway:config:updates"
+
+var errFingerprintMismatch = errors.New("config: fingerprint does not match current config")
+
+// ConfigHandler lets Config be read and mutated at runtime without a
+// restart. Every mutation goes through DoLockedAction so concurrent admins
+// can't clobber each other's changes: callers must present the fingerprint
+// they last read, and a mismatch means someone else updated the config in
+// between.
+type ConfigHandler struct {
+	gateway *Gateway
+}
+
+func NewConfigHandler(g *Gateway) *ConfigHandler {
+	return &ConfigHandler{gateway: g}
+}
+
+// Fingerprint returns a stable SHA-256 hash of the config's canonical JSON
+// serialization.
+func (h *ConfigHandler) Fingerprint() (string, error) {
+	return fingerprintConfig(h.gateway.Config())
+}
+
+func fingerprintConfig(cfg *Config) (string, error) {
+	canonical, err := canonicalJSON(cfg)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalJSON re-marshals the config through a generic value so map keys
+// come out sorted, making the fingerprint independent of map iteration
+// order.
+func canonicalJSON(cfg *Config) ([]byte, error) {
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
+
+// DoLockedAction validates expectedFingerprint against the current config
+// (skipping the check when empty), then runs fn to produce the replacement
+// config and applies it atomically. It returns the applied config and its
+// new fingerprint, or errFingerprintMismatch if expectedFingerprint was
+// stale.
+func (h *ConfigHandler) DoLockedAction(expectedFingerprint string, fn func(current *Config) (*Config, error)) (*Config, string, error) {
+	h.gateway.configMu.Lock()
+	defer h.gateway.configMu.Unlock()
+
+	current := h.gateway.Config()
+	actual, err := fingerprintConfig(current)
+	if err != nil {
+		return nil, "", err
+	}
+	if expectedFingerprint != "" && expectedFingerprint != actual {
+		return nil, actual, errFingerprintMismatch
+	}
+
+	next, err := fn(current)
+	if err != nil {
+		return nil, actual, err
+	}
+
+	if err := validateConfig(next); err != nil {
+		return nil, actual, err
+	}
+
+	h.gateway.applyConfig(next)
+
+	newFingerprint, err := fingerprintConfig(next)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return next, newFingerprint, nil
+}
+
+func validateConfig(cfg *Config) error {
+	if cfg.Port == "" {
+		return fmt.Errorf("port must not be empty")
+	}
+	if cfg.RateLimitRPM <= 0 {
+		return fmt.Errorf("rateLimitRpm must be positive")
+	}
+	if cfg.CircuitBreakerThreshold <= 0 {
+		return fmt.Errorf("circuitBreakerThreshold must be positive")
+	}
+	return nil
+}
+
+// applyConfig swaps in the new config and rebuilds the components that
+// depend on it. It must be called with gateway.configMu held.
+func (g *Gateway) applyConfig(next *Config) {
+	g.config.Store(next)
+
+	// Rate limiters are cheap to rebuild from scratch; swapping them in
+	// after the config pointer avoids a window where some requests see the
+	// old policy and some see the new one applied to stale limiter state.
+	// They're stored atomically because rateLimitMiddleware reads them on
+	// every request concurrently with this reload. Existing circuit
+	// breakers keep their state and only take the new failure threshold.
+	var store RateLimitStore = NewRedisRateLimitStore(g.redisClient)
+	g.rateLimitStore.Store(&store)
+	g.fallbackLimitStore.Store(NewInMemoryRateLimitStore())
+
+	for _, svc := range g.registry.List() {
+		svc.CircuitBreaker.SetFailureThreshold(next.CircuitBreakerThreshold)
+	}
+
+	// Upstream URLs and routes.
+	g.reconcileServices(next)
+
+	// CORS allowed origins.
+	g.corsHandler.Store(buildCORSHandler(next))
+
+	// OIDC issuers: the old provider set's background refresh goroutines are
+	// canceled only after the new set is live, so lookupJWKSKey never sees a
+	// gap with no providers configured.
+	newJWKS := g.buildJWKSProviders(next)
+	oldJWKS := g.jwks.Swap(newJWKS)
+	if oldJWKS != nil {
+		oldJWKS.cancel()
+	}
+}
+
+// persistAndNotify writes cfg to Redis and publishes a notification so peer
+// gateway instances reload it. Failures are logged, not fatal — the local
+// update already succeeded.
+func (h *ConfigHandler) persistAndNotify(ctx context.Context, cfg *Config) {
+	encoded, err := json.Marshal(cfg)
+	if err != nil {
+		log.Printf("⚠️  Failed to marshal config for persistence: %v", err)
+		return
+	}
+
+	if err := h.gateway.redisClient.Set(ctx, configRedisKey, encoded, 0).Err(); err != nil {
+		log.Printf("⚠️  Failed to persist config to Redis: %v", err)
+		return
+	}
+
+	if err := h.gateway.redisClient.Publish(ctx, configUpdateChannel, "reload").Err(); err != nil {
+		log.Printf("⚠️  Failed to publish config reload notification: %v", err)
+	}
+}
+
+// StartPeerSync subscribes to the config update channel and reloads from
+// Redis whenever another gateway replica publishes a change.
+func (h *ConfigHandler) StartPeerSync(ctx context.Context) {
+	sub := h.gateway.redisClient.Subscribe(ctx, configUpdateChannel)
+
+	go func() {
+		defer sub.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-sub.Channel():
+				if !ok {
+					return
+				}
+				if err := h.reloadFromRedis(ctx); err != nil {
+					configReloadErrorsTotal.WithLabelValues("peer").Inc()
+					log.Printf("⚠️  Peer config reload failed: %v", err)
+					continue
+				}
+				configReloadsTotal.WithLabelValues("peer").Inc()
+			}
+		}
+	}()
+}
+
+func (h *ConfigHandler) reloadFromRedis(ctx context.Context) error {
+	raw, err := h.gateway.redisClient.Get(ctx, configRedisKey).Bytes()
+	if err != nil {
+		return err
+	}
+
+	h.gateway.configMu.Lock()
+	defer h.gateway.configMu.Unlock()
+
+	// Decode into a fresh Config rather than a copy of the live one, so the
+	// live config's maps and slices are never written to in place.
+	current := h.gateway.Config()
+	var next Config
+	if err := json.Unmarshal(raw, &next); err != nil {
+		return err
+	}
+	// Secrets aren't part of the persisted form; carry them over from the
+	// config being replaced.
+	next.JWTSecret = current.JWTSecret
+	next.AdminKey = current.AdminKey
+	if next.Introspection != nil && current.Introspection != nil {
+		next.Introspection.ClientSecret = current.Introspection.ClientSecret
+	}
+	if err := validateConfig(&next); err != nil {
+		return err
+	}
+
+	h.gateway.applyConfig(&next)
+	return nil
+}
+
+// getConfigHandler returns the live config alongside its fingerprint, both in
+// the body and in the ETag header; admins echo it back in If-Match on writes.
+func (g *Gateway) getConfigHandler(w http.ResponseWriter, r *http.Request) {
+	fingerprint, err := g.configHandler.Fingerprint()
+	if err != nil {
+		http.Error(w, `{"error":"Failed to compute config fingerprint"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", fingerprint)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config":      g.Config(),
+		"fingerprint": fingerprint,
+	})
+}
+
+func (g *Gateway) putConfigHandler(w http.ResponseWriter, r *http.Request) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, `{"error":"If-Match header is required"}`, http.StatusPreconditionRequired)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1024*1024)
+
+	var replacement Config
+	if err := json.NewDecoder(r.Body).Decode(&replacement); err != nil {
+		http.Error(w, `{"error":"Invalid config JSON"}`, http.StatusBadRequest)
+		return
+	}
+
+	applied, fingerprint, err := g.configHandler.DoLockedAction(ifMatch, func(current *Config) (*Config, error) {
+		// Secrets aren't part of the wire format; carry them over from the
+		// config being replaced.
+		replacement.JWTSecret = current.JWTSecret
+		replacement.AdminKey = current.AdminKey
+		if replacement.Introspection != nil && current.Introspection != nil {
+			replacement.Introspection.ClientSecret = current.Introspection.ClientSecret
+		}
+		return &replacement, nil
+	})
+
+	if errors.Is(err, errFingerprintMismatch) {
+		configReloadErrorsTotal.WithLabelValues("admin").Inc()
+		w.Header().Set("ETag", fingerprint)
+		http.Error(w, `{"error":"Config was modified by another request"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		configReloadErrorsTotal.WithLabelValues("admin").Inc()
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	configReloadsTotal.WithLabelValues("admin").Inc()
+	g.configHandler.persistAndNotify(r.Context(), applied)
+
+	w.Header().Set("ETag", fingerprint)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"config":      applied,
+		"fingerprint": fingerprint,
+	})
+}
+
+// patchConfigHandler updates a single top-level config field named by the
+// {jsonPath} route variable (the field's JSON tag), e.g.
+// PATCH /api/v1/admin/config/rateLimitRpm with body `{"value": 200}`.
+func (g *Gateway) patchConfigHandler(w http.ResponseWriter, r *http.Request) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, `{"error":"If-Match header is required"}`, http.StatusPreconditionRequired)
+		return
+	}
+
+	jsonPath := mux.Vars(r)["jsonPath"]
+
+	var body struct {
+		Value json.RawMessage `json:"value"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, `{"error":"Invalid patch body"}`, http.StatusBadRequest)
+		return
+	}
+
+	_, fingerprint, err := g.configHandler.DoLockedAction(ifMatch, func(current *Config) (*Config, error) {
+		next := *current
+		if err := patchConfigField(&next, jsonPath, body.Value); err != nil {
+			return nil, err
+		}
+		return &next, nil
+	})
+
+	if errors.Is(err, errFingerprintMismatch) {
+		configReloadErrorsTotal.WithLabelValues("admin").Inc()
+		http.Error(w, `{"error":"Config was modified by another request"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		configReloadErrorsTotal.WithLabelValues("admin").Inc()
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	configReloadsTotal.WithLabelValues("admin").Inc()
+	g.configHandler.persistAndNotify(r.Context(), g.Config())
+
+	w.Header().Set("ETag", fingerprint)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"fingerprint": fingerprint})
+}
+
+// patchConfigField sets one of the handful of fields that are safe to tune
+// live via a partial update. cfg is a shallow copy of the live config, so
+// map and struct fields are reset before decoding: the value replaces the
+// field instead of being merged into maps the live config still shares.
+func patchConfigField(cfg *Config, jsonPath string, value json.RawMessage) error {
+	switch jsonPath {
+	case "rateLimitRpm":
+		return json.Unmarshal(value, &cfg.RateLimitRPM)
+	case "circuitBreakerThreshold":
+		return json.Unmarshal(value, &cfg.CircuitBreakerThreshold)
+	case "trustedProxyHops":
+		return json.Unmarshal(value, &cfg.TrustedProxyHops)
+	case "rateLimitPolicies":
+		cfg.RateLimitPolicies = nil
+		return json.Unmarshal(value, &cfg.RateLimitPolicies)
+	case "rateLimitBypass":
+		cfg.RateLimitBypass = RateLimitBypass{}
+		return json.Unmarshal(value, &cfg.RateLimitBypass)
+	case "routeRoles":
+		cfg.RouteRoles = nil
+		return json.Unmarshal(value, &cfg.RouteRoles)
+	default:
+		return fmt.Errorf("unknown or non-patchable config field %q", jsonPath)
+	}
+}
+
+// exportConfigHandler returns the current config as YAML for GitOps
+// workflows that track it in a repository.
+func (g *Gateway) exportConfigHandler(w http.ResponseWriter, r *http.Request) {
+	encoded, err := yaml.Marshal(g.Config())
+	if err != nil {
+		http.Error(w, `{"error":"Failed to export config"}`, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(encoded)
+}
+
+// importConfigHandler applies a YAML config, e.g. one checked out from a
+// GitOps repository, the same way putConfigHandler applies a JSON one.
+func (g *Gateway) importConfigHandler(w http.ResponseWriter, r *http.Request) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, `{"error":"If-Match header is required"}`, http.StatusPreconditionRequired)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1024*1024)
+
+	var replacement Config
+	if err := yaml.NewDecoder(r.Body).Decode(&replacement); err != nil {
+		http.Error(w, `{"error":"Invalid config YAML"}`, http.StatusBadRequest)
+		return
+	}
+
+	applied, fingerprint, err := g.configHandler.DoLockedAction(ifMatch, func(current *Config) (*Config, error) {
+		replacement.JWTSecret = current.JWTSecret
+		replacement.AdminKey = current.AdminKey
+		if replacement.Introspection != nil && current.Introspection != nil {
+			replacement.Introspection.ClientSecret = current.Introspection.ClientSecret
+		}
+		return &replacement, nil
+	})
+
+	if errors.Is(err, errFingerprintMismatch) {
+		configReloadErrorsTotal.WithLabelValues("admin").Inc()
+		http.Error(w, `{"error":"Config was modified by another request"}`, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		configReloadErrorsTotal.WithLabelValues("admin").Inc()
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	configReloadsTotal.WithLabelValues("admin").Inc()
+	g.configHandler.persistAndNotify(r.Context(), applied)
+
+	w.Header().Set("ETag", fingerprint)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"fingerprint": fingerprint})
+}
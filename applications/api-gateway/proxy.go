@@ -0,0 +1,447 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// proxyBody holds a request body for one or more upstream attempts. Bodies
+// no larger than a service's MaxRetryBodyBytes are buffered in full so they
+// can be replayed on retry or hedged to a second upstream; larger bodies are
+// streamed through once, unbuffered, and can't be retried or hedged.
+type proxyBody struct {
+	buffered  []byte
+	retryable bool
+	first     io.Reader // set only when !retryable; consumed by the one attempt allowed
+}
+
+// newProxyBody reads up to limit+1 bytes of r's body to decide whether it
+// fits the retry/hedge buffer. Bodies at or under limit are fully buffered;
+// anything larger is wrapped back up (already-read prefix plus the rest of
+// the stream) so the single attempt still sees the complete body.
+func newProxyBody(r *http.Request, limit int64) (*proxyBody, error) {
+	if r.Body == nil {
+		return &proxyBody{retryable: true}, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) <= limit {
+		return &proxyBody{buffered: data, retryable: true}, nil
+	}
+
+	return &proxyBody{retryable: false, first: io.MultiReader(bytes.NewReader(data), r.Body)}, nil
+}
+
+// reader returns an io.Reader for the next attempt. For a buffered
+// (retryable) body this can be called any number of times; for a streamed
+// (non-retryable) body it returns the stream once and nil thereafter.
+func (b *proxyBody) reader() io.Reader {
+	if b.retryable {
+		return bytes.NewReader(b.buffered)
+	}
+	first := b.first
+	b.first = nil
+	return first
+}
+
+// trimmedForwardedFor rebuilds X-Forwarded-For for the upstream request,
+// keeping only the suffix of the incoming chain added by proxies this
+// gateway trusts (an untrusted client could have prepended arbitrary entries
+// before those) and appending the gateway's own view of its immediate peer.
+func trimmedForwardedFor(r *http.Request, trustedHops int) string {
+	var hops []string
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		for _, hop := range strings.Split(xff, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+
+	if trustedHops < 0 {
+		trustedHops = 0
+	}
+	if len(hops) > trustedHops {
+		hops = hops[len(hops)-trustedHops:]
+	}
+
+	hops = append(hops, strings.Split(r.RemoteAddr, ":")[0])
+	return strings.Join(hops, ", ")
+}
+
+// retryBackoff returns the delay before retry attempt n (n >= 1): exponential
+// backoff from base, with up to 50% jitter so a burst of failing requests
+// doesn't all retry against the upstream at the same instant.
+func retryBackoff(attempt int, base time.Duration) time.Duration {
+	if base <= 0 {
+		base = 50 * time.Millisecond
+	}
+
+	const maxBackoff = 2 * time.Second
+	backoff := base << uint(attempt-1)
+	if backoff <= 0 || backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	return backoff/2 + time.Duration(rand.Int63n(int64(backoff)/2+1))
+}
+
+// proxyAttempt is the outcome of one request to one upstream.
+type proxyAttempt struct {
+	resp     *http.Response
+	upstream *Upstream
+	err      error
+}
+
+// doUpstreamRequest issues a single attempt against upstream, propagating
+// tracing headers and a trimmed X-Forwarded-For, and records the upstream's
+// latency for future hedging decisions. A 5xx response is treated as a
+// transient failure so the caller can retry or hedge it. Latency is recorded
+// only for a successful response: a canceled hedge loser or a dial error
+// would otherwise fold a near-zero duration into the sample window that
+// decides how soon future requests get hedged.
+func (g *Gateway) doUpstreamRequest(ctx context.Context, r *http.Request, svc *ServiceProxy, upstream *Upstream, body io.Reader, requestID, forwardedFor string) (*http.Response, error) {
+	targetURL := upstream.URL + r.URL.Path
+	if r.URL.RawQuery != "" {
+		targetURL += "?" + r.URL.RawQuery
+	}
+
+	proxyReq, err := http.NewRequestWithContext(ctx, r.Method, targetURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy safe headers only, plus W3C trace context so spans link up across
+	// the proxy hop.
+	safeHeaders := []string{"Content-Type", "Accept", "User-Agent", "X-User-ID", "Traceparent"}
+	for _, header := range safeHeaders {
+		if value := r.Header.Get(header); value != "" {
+			proxyReq.Header.Set(header, value)
+		}
+	}
+	proxyReq.Header.Set("X-Request-ID", requestID)
+	proxyReq.Header.Set("X-Forwarded-For", forwardedFor)
+
+	start := time.Now()
+	resp, err := svc.Client.Do(proxyReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 500 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("upstream %s returned %d", upstream.URL, resp.StatusCode)
+	}
+
+	upstream.recordLatency(time.Since(start))
+	return resp, nil
+}
+
+// sendWithHedge sends one attempt to primary, speculatively racing a second
+// upstream if primary hasn't answered within its own tracked latency
+// percentile. Whichever response arrives first wins; the loser is canceled
+// and its response body (if any) is closed in the background.
+func (g *Gateway) sendWithHedge(r *http.Request, svc *ServiceProxy, primary *Upstream, body *proxyBody, requestID, forwardedFor string) (*http.Response, *Upstream, error) {
+	ctx, cancel := context.WithCancel(r.Context())
+
+	results := make(chan proxyAttempt, 2)
+	go func() {
+		resp, err := g.doUpstreamRequest(ctx, r, svc, primary, body.reader(), requestID, forwardedFor)
+		results <- proxyAttempt{resp, primary, err}
+	}()
+
+	var hedgeDelay time.Duration
+	if svc.HedgeEnabled && body.retryable {
+		hedgeDelay = primary.latencyPercentile(svc.HedgePercentile)
+	}
+
+	if hedgeDelay <= 0 {
+		first := <-results
+		cancel()
+		return first.resp, first.upstream, first.err
+	}
+
+	timer := time.NewTimer(hedgeDelay)
+	defer timer.Stop()
+
+	select {
+	case first := <-results:
+		cancel()
+		return first.resp, first.upstream, first.err
+
+	case <-timer.C:
+		secondary, err := svc.Pick()
+		canHedge := err == nil && secondary.URL != primary.URL &&
+			(svc.BackendLimiter == nil || svc.BackendLimiter.Allow(secondary.URL))
+
+		if canHedge {
+			go func() {
+				resp, err := g.doUpstreamRequest(ctx, r, svc, secondary, body.reader(), requestID, forwardedFor)
+				results <- proxyAttempt{resp, secondary, err}
+			}()
+		}
+
+		first := <-results
+		cancel()
+
+		if canHedge {
+			go func() {
+				if loser := <-results; loser.resp != nil {
+					loser.resp.Body.Close()
+				}
+			}()
+		}
+
+		return first.resp, first.upstream, first.err
+	}
+}
+
+// proxyWithRetryAndHedge sends the request to svc, retrying on transient
+// failure with exponential backoff+jitter as long as the circuit breaker
+// stays closed and the body is replayable, and hedging each attempt per
+// sendWithHedge.
+func (g *Gateway) proxyWithRetryAndHedge(r *http.Request, svc *ServiceProxy, serviceName string, body *proxyBody, requestID, forwardedFor string) (*http.Response, *Upstream, error) {
+	maxAttempts := svc.MaxRetries + 1
+	if !body.retryable {
+		maxAttempts = 1
+	}
+
+	var lastErr error
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if attempt > 0 {
+			if !svc.CircuitBreaker.canExecute() {
+				break
+			}
+			time.Sleep(retryBackoff(attempt, svc.RetryBaseDelay))
+		}
+
+		upstream, err := svc.Pick()
+		if err != nil {
+			svc.CircuitBreaker.recordFailure()
+			lastErr = err
+			break
+		}
+
+		if svc.BackendLimiter != nil && !svc.BackendLimiter.Allow(upstream.URL) {
+			lastErr = fmt.Errorf("backend rate limit exceeded for upstream %s", upstream.URL)
+			continue
+		}
+
+		resp, respUpstream, err := g.sendWithHedge(r, svc, upstream, body, requestID, forwardedFor)
+		if err != nil {
+			svc.CircuitBreaker.recordFailure()
+			upstreamRequestsTotal.WithLabelValues(serviceName, upstream.URL, "error").Inc()
+			log.Printf("%s upstream %s error: %v", serviceName, upstream.URL, err)
+			lastErr = err
+			continue
+		}
+
+		svc.CircuitBreaker.recordSuccess()
+		upstreamRequestsTotal.WithLabelValues(serviceName, respUpstream.URL, strconv.Itoa(resp.StatusCode)).Inc()
+		return resp, respUpstream, nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no healthy upstream available")
+	}
+	return nil, nil, lastErr
+}
+
+// proxyHandler dispatches a request to whichever service the route table
+// binds the request's path and method to. Small request bodies are buffered
+// so they can be retried and hedged across upstreams; large bodies stream
+// through a single, non-retryable attempt.
+func (g *Gateway) proxyHandler(w http.ResponseWriter, r *http.Request) {
+	serviceName, ok := g.registry.ServiceForPath(r.Method, r.URL.Path)
+	if !ok {
+		http.Error(w, `{"error":"No service registered for this route"}`, http.StatusNotFound)
+		return
+	}
+
+	svc, ok := g.registry.Get(serviceName)
+	if !ok {
+		http.Error(w, `{"error":"Service not found"}`, http.StatusNotFound)
+		return
+	}
+
+	if !svc.CircuitBreaker.canExecute() {
+		http.Error(w, `{"error":"Service temporarily unavailable"}`, http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := newProxyBody(r, svc.MaxRetryBodyBytes)
+	if err != nil {
+		http.Error(w, `{"error":"Failed to read request body"}`, http.StatusBadRequest)
+		return
+	}
+
+	requestID := generateSecureRequestID()
+	w.Header().Set("X-Request-ID", requestID)
+	forwardedFor := trimmedForwardedFor(r, g.Config().TrustedProxyHops)
+
+	resp, _, err := g.proxyWithRetryAndHedge(r, svc, serviceName, body, requestID, forwardedFor)
+	if err != nil {
+		http.Error(w, `{"error":"Service unavailable"}`, http.StatusServiceUnavailable)
+		return
+	}
+	defer resp.Body.Close()
+
+	// Copy response headers (safe ones only)
+	safeResponseHeaders := []string{"Content-Type", "Content-Length", "X-Request-ID"}
+	for _, header := range safeResponseHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			w.Header().Set(header, value)
+		}
+	}
+
+	w.WriteHeader(resp.StatusCode)
+
+	// Copy body with size limit
+	const maxResponseSize = 10 * 1024 * 1024 // 10MB limit
+	limitedReader := http.MaxBytesReader(w, resp.Body, maxResponseSize)
+
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := limitedReader.Read(buf)
+		if n > 0 {
+			w.Write(buf[:n])
+		}
+		if err != nil {
+			break
+		}
+	}
+}
+
+// servicesHandler lists, adds, drains, or removes upstreams at runtime.
+func (g *Gateway) servicesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		g.listServicesHandler(w, r)
+	case http.MethodPost:
+		g.mutateServicesHandler(w, r)
+	default:
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
+
+type upstreamStatus struct {
+	URL     string `json:"url"`
+	Weight  int    `json:"weight"`
+	Healthy bool   `json:"healthy"`
+}
+
+type serviceStatus struct {
+	Name           string           `json:"name"`
+	CircuitBreaker string           `json:"circuit_breaker_state"`
+	Upstreams      []upstreamStatus `json:"upstreams"`
+}
+
+func (g *Gateway) listServicesHandler(w http.ResponseWriter, r *http.Request) {
+	services := []serviceStatus{}
+	for _, svc := range g.registry.List() {
+		status := serviceStatus{Name: svc.Name, CircuitBreaker: svc.CircuitBreaker.State()}
+		for _, u := range svc.Upstreams() {
+			status.Upstreams = append(status.Upstreams, upstreamStatus{URL: u.URL, Weight: u.Weight, Healthy: u.Healthy()})
+		}
+		services = append(services, status)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"services": services})
+}
+
+type serviceMutationRequest struct {
+	Action  string `json:"action"` // "add", "drain", "remove"
+	Service string `json:"service"`
+	URL     string `json:"url"`
+	Weight  int    `json:"weight"`
+}
+
+func (g *Gateway) mutateServicesHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+
+	var req serviceMutationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":"Invalid request"}`, http.StatusBadRequest)
+		return
+	}
+
+	svc, ok := g.registry.Get(req.Service)
+	if !ok {
+		http.Error(w, `{"error":"Service not found"}`, http.StatusNotFound)
+		return
+	}
+
+	switch req.Action {
+	case "add":
+		if req.URL == "" {
+			http.Error(w, `{"error":"url is required"}`, http.StatusBadRequest)
+			return
+		}
+		svc.AddUpstream(&Upstream{URL: req.URL, Weight: req.Weight})
+	case "drain":
+		if !svc.DrainUpstream(req.URL) {
+			http.Error(w, `{"error":"Upstream not found"}`, http.StatusNotFound)
+			return
+		}
+	case "remove":
+		if !svc.RemoveUpstream(req.URL) {
+			http.Error(w, `{"error":"Upstream not found"}`, http.StatusNotFound)
+			return
+		}
+	default:
+		http.Error(w, fmt.Sprintf(`{"error":"Unknown action %q"}`, req.Action), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// circuitBreakerHandler reports or resets the circuit breaker for any
+// registered service (previously hardcoded to "payment-service").
+func (g *Gateway) circuitBreakerHandler(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	service := vars["service"]
+	svc, ok := g.registry.Get(service)
+	if !ok {
+		http.Error(w, `{"error":"Service not found"}`, http.StatusNotFound)
+		return
+	}
+	cb := svc.CircuitBreaker
+
+	switch r.Method {
+	case "GET":
+		stats := cb.Stats()
+		state := map[string]interface{}{
+			"state":             stats.State,
+			"failure_count":     stats.FailureCount,
+			"success_count":     stats.SuccessCount,
+			"failure_threshold": stats.FailureThreshold,
+			"timeout_seconds":   stats.Timeout.Seconds(),
+			"last_fail_time":    stats.LastFailTime,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+	case "POST":
+		cb.Reset()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "reset"})
+	default:
+		http.Error(w, `{"error":"Method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// RateLimitPolicy describes the limit applied to a group of routes.
+type RateLimitPolicy struct {
+	Name   string        `json:"name" yaml:"name"`
+	RPM    int           `json:"rpm" yaml:"rpm"`
+	Burst  int           `json:"burst" yaml:"burst"`
+	Window time.Duration `json:"window" yaml:"window"`
+}
+
+// RateLimitStore is implemented by anything that can answer "is this key
+// allowed to make another request under this policy right now". It lets the
+// gateway share limiter state across replicas instead of keeping it
+// in-process.
+type RateLimitStore interface {
+	Allow(ctx context.Context, policy RateLimitPolicy, key string) (bool, error)
+}
+
+// RedisRateLimitStore implements RateLimitStore with a fixed-window counter:
+// INCR the window bucket and EXPIRE it the first time it's created. This
+// costs a single round-trip per request (two for the first request of each
+// window, which also sets the expiry) and matches the counting scheme
+// already used for fraud velocity checks.
+type RedisRateLimitStore struct {
+	client *redis.Client
+}
+
+func NewRedisRateLimitStore(client *redis.Client) *RedisRateLimitStore {
+	return &RedisRateLimitStore{client: client}
+}
+
+func (s *RedisRateLimitStore) Allow(ctx context.Context, policy RateLimitPolicy, key string) (bool, error) {
+	window := policy.Window
+	if window <= 0 {
+		window = time.Minute
+	}
+
+	bucket := time.Now().UnixNano() / window.Nanoseconds()
+	redisKey := fmt.Sprintf("ratelimit:%s:%s:%d", policy.Name, key, bucket)
+
+	count, err := s.client.Incr(ctx, redisKey).Result()
+	if err != nil {
+		return false, err
+	}
+	if count == 1 {
+		s.client.Expire(ctx, redisKey, window)
+	}
+
+	limit := int64(policy.RPM)
+	if policy.Burst > policy.RPM {
+		limit = int64(policy.Burst)
+	}
+
+	return count <= limit, nil
+}
+
+// InMemoryRateLimitStore wraps golang.org/x/time/rate limiters keyed by
+// policy+key. It is used when the Redis-backed store is unavailable so the
+// gateway degrades to per-replica limiting rather than failing open.
+type InMemoryRateLimitStore struct {
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func NewInMemoryRateLimitStore() *InMemoryRateLimitStore {
+	return &InMemoryRateLimitStore{limiters: make(map[string]*rate.Limiter)}
+}
+
+func (s *InMemoryRateLimitStore) Allow(ctx context.Context, policy RateLimitPolicy, key string) (bool, error) {
+	limiterKey := policy.Name + ":" + key
+
+	s.mu.Lock()
+	limiter, exists := s.limiters[limiterKey]
+	if !exists {
+		rpm := policy.RPM
+		if rpm <= 0 {
+			rpm = 1
+		}
+		burst := policy.Burst
+		if burst <= 0 {
+			burst = rpm
+		}
+		limiter = rate.NewLimiter(rate.Every(time.Minute/time.Duration(rpm)), burst)
+		s.limiters[limiterKey] = limiter
+	}
+	s.mu.Unlock()
+
+	return limiter.Allow(), nil
+}
+
+// BackendRateLimiter guards how much traffic a ServiceProxy sends to one
+// upstream. Unlike RateLimitStore, which limits a single client's request
+// rate at the edge, this limits the aggregate rate this gateway forwards to
+// a backend, so retries, hedged requests, or one misbehaving client can't
+// exhaust a downstream service.
+type BackendRateLimiter interface {
+	Allow(upstreamURL string) bool
+}
+
+// InMemoryBackendRateLimiter enforces a fixed requests-per-second budget per
+// upstream URL using an in-process token bucket. It's process-local by
+// design: backend protection only needs to bound what this gateway instance
+// sends, not a cluster-wide total.
+type InMemoryBackendRateLimiter struct {
+	rps float64
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+func NewInMemoryBackendRateLimiter(rps float64) *InMemoryBackendRateLimiter {
+	return &InMemoryBackendRateLimiter{rps: rps, limiters: make(map[string]*rate.Limiter)}
+}
+
+func (b *InMemoryBackendRateLimiter) Allow(upstreamURL string) bool {
+	b.mu.Lock()
+	limiter, exists := b.limiters[upstreamURL]
+	if !exists {
+		burst := int(b.rps)
+		if burst < 1 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(b.rps), burst)
+		b.limiters[upstreamURL] = limiter
+	}
+	b.mu.Unlock()
+
+	return limiter.Allow()
+}
+
+// RateLimitBypass matches requests that should skip rate limiting entirely,
+// e.g. internal health probes or trusted partner origins.
+type RateLimitBypass struct {
+	UserAgents []string `json:"userAgents" yaml:"userAgents"`
+	Origins    []string `json:"origins" yaml:"origins"`
+}
+
+func (b RateLimitBypass) matches(r *http.Request) bool {
+	ua := r.Header.Get("User-Agent")
+	for _, substr := range b.UserAgents {
+		if substr != "" && strings.Contains(ua, substr) {
+			return true
+		}
+	}
+
+	origin := r.Header.Get("Origin")
+	for _, allowed := range b.Origins {
+		if allowed != "" && origin == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// policyForPath returns the RateLimitPolicy bound to the route group a
+// request path belongs to, falling back to the default policy. Map
+// iteration order is random, so the longest matching prefix wins rather
+// than whichever one the range happens to hit first.
+func (g *Gateway) policyForPath(path string) RateLimitPolicy {
+	policies := g.Config().RateLimitPolicies
+
+	var best string
+	var bestPolicy RateLimitPolicy
+	found := false
+	for prefix, policy := range policies {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(best) {
+			best = prefix
+			bestPolicy = policy
+			found = true
+		}
+	}
+	if !found {
+		return g.Config().DefaultRateLimitPolicy
+	}
+	return bestPolicy
+}
+
+func (g *Gateway) rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.Config().RateLimitBypass.matches(r) {
+			rateLimitBypassed.WithLabelValues(sanitizePath(r.URL.Path)).Inc()
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		clientIP := getClientIP(r, g.Config().TrustedProxyHops)
+		policy := g.policyForPath(r.URL.Path)
+
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+
+		allowed, err := g.RateLimitStore().Allow(ctx, policy, clientIP)
+		if err != nil {
+			log.Printf("⚠️  Rate limit store error, falling back to in-process limiter: %v", err)
+			allowed, _ = g.FallbackLimitStore().Allow(ctx, policy, clientIP)
+		}
+
+		if !allowed {
+			rateLimitHits.WithLabelValues(clientIP, sanitizePath(r.URL.Path)).Inc()
+			securityEvents.WithLabelValues("rate_limit_exceeded", clientIP).Inc()
+
+			w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", policy.RPM))
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("Retry-After", "60")
+
+			http.Error(w, `{"error":"Rate limit exceeded"}`, http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
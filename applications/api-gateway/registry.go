@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// latencySampleWindow bounds how many recent latency samples an Upstream
+// keeps, so percentile estimates track recent behavior instead of growing
+// unbounded over the upstream's lifetime.
+const latencySampleWindow = 200
+
+var upstreamRequestsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "upstream_requests_total",
+		Help: "Total number of requests proxied to a specific upstream",
+	},
+	[]string{"service", "upstream", "status"},
+)
+
+func init() {
+	prometheus.MustRegister(upstreamRequestsTotal)
+}
+
+// Upstream is one backend instance behind a ServiceProxy.
+type Upstream struct {
+	URL    string `json:"url" yaml:"url"`
+	Weight int    `json:"weight" yaml:"weight"`
+
+	healthy int32 // atomic bool, updated by background health checks
+	drained int32 // atomic bool, set by DrainUpstream; health checks skip it
+
+	latencyMu      sync.Mutex
+	latencySamples []time.Duration
+	latencyNext    int
+}
+
+func (u *Upstream) Healthy() bool { return atomic.LoadInt32(&u.healthy) == 1 }
+
+func (u *Upstream) setHealthy(ok bool) {
+	if ok {
+		atomic.StoreInt32(&u.healthy, 1)
+	} else {
+		atomic.StoreInt32(&u.healthy, 0)
+	}
+}
+
+// recordLatency adds d to the upstream's rolling latency window, overwriting
+// the oldest sample once the window is full.
+func (u *Upstream) recordLatency(d time.Duration) {
+	u.latencyMu.Lock()
+	defer u.latencyMu.Unlock()
+
+	if len(u.latencySamples) < latencySampleWindow {
+		u.latencySamples = append(u.latencySamples, d)
+		return
+	}
+	u.latencySamples[u.latencyNext] = d
+	u.latencyNext = (u.latencyNext + 1) % latencySampleWindow
+}
+
+// latencyPercentile returns the p-th percentile (0 < p <= 1) of the
+// upstream's recently recorded latencies, or 0 if too few samples have been
+// recorded yet to make a meaningful estimate.
+func (u *Upstream) latencyPercentile(p float64) time.Duration {
+	u.latencyMu.Lock()
+	defer u.latencyMu.Unlock()
+
+	if len(u.latencySamples) < 10 {
+		return 0
+	}
+
+	sorted := append([]time.Duration(nil), u.latencySamples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	idx := int(p * float64(len(sorted)))
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
+
+// RouteRule maps an inbound path prefix (and optional method list) to a
+// registered service, replacing the gateway's old hardcoded path-to-handler
+// wiring.
+type RouteRule struct {
+	PathPrefix string   `json:"pathPrefix" yaml:"pathPrefix"`
+	Methods    []string `json:"methods" yaml:"methods"`
+	Service    string   `json:"service" yaml:"service"`
+}
+
+// ServiceProxy is a named backend service load balanced across one or more
+// upstream instances, each guarded by the service's own circuit breaker.
+type ServiceProxy struct {
+	Name            string
+	HealthCheckPath string
+	Timeout         time.Duration
+	Client          *http.Client
+	CircuitBreaker  *CircuitBreaker
+
+	// MaxRetries, RetryBaseDelay and MaxRetryBodyBytes bound proxyHandler's
+	// retry behavior: requests with a body no larger than MaxRetryBodyBytes
+	// are buffered so they can be replayed up to MaxRetries times on
+	// transient failure, with exponential backoff (from RetryBaseDelay) plus
+	// jitter between attempts.
+	MaxRetries        int
+	RetryBaseDelay    time.Duration
+	MaxRetryBodyBytes int64
+
+	// HedgeEnabled and HedgePercentile control request hedging: once the
+	// primary upstream has been outstanding longer than its own
+	// HedgePercentile latency (tracked per Upstream), a second request is
+	// fired at another healthy upstream and whichever responds first wins.
+	HedgeEnabled    bool
+	HedgePercentile float64
+
+	// BackendLimiter, if set, caps the rate of requests forwarded to any one
+	// upstream regardless of how many clients or retries are driving it.
+	BackendLimiter BackendRateLimiter
+
+	mu        sync.RWMutex
+	upstreams []*Upstream
+	rrCounter uint64
+}
+
+func NewServiceProxy(name string, timeout time.Duration, cbThreshold int) *ServiceProxy {
+	return &ServiceProxy{
+		Name:            name,
+		HealthCheckPath: "/health",
+		Timeout:         timeout,
+		Client:          &http.Client{Timeout: timeout},
+		CircuitBreaker: &CircuitBreaker{
+			failureThreshold: cbThreshold,
+			timeout:          60 * time.Second,
+			state:            "closed",
+		},
+		MaxRetryBodyBytes: 1 << 20, // 1MiB
+		RetryBaseDelay:    50 * time.Millisecond,
+		HedgePercentile:   0.95,
+	}
+}
+
+// ApplyTLSConfig switches the proxy's HTTP client onto an mTLS-enabled
+// transport that trusts tlsConfig's CA bundle and presents its client
+// certificate to every upstream.
+func (p *ServiceProxy) ApplyTLSConfig(tlsConfig *ServiceTLSConfig) error {
+	clientTLS, err := tlsConfig.buildClientTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	p.Client = &http.Client{
+		Timeout:   p.Timeout,
+		Transport: &http.Transport{TLSClientConfig: clientTLS},
+	}
+	return nil
+}
+
+func (p *ServiceProxy) AddUpstream(u *Upstream) {
+	u.setHealthy(true)
+	p.mu.Lock()
+	p.upstreams = append(p.upstreams, u)
+	p.mu.Unlock()
+}
+
+// RemoveUpstream drops an upstream entirely (used when it's decommissioned).
+func (p *ServiceProxy) RemoveUpstream(url string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, u := range p.upstreams {
+		if u.URL == url {
+			p.upstreams = append(p.upstreams[:i], p.upstreams[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// DrainUpstream marks an upstream unhealthy without removing it, so it stops
+// receiving new traffic but its config/metrics history is preserved.
+func (p *ServiceProxy) DrainUpstream(url string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, u := range p.upstreams {
+		if u.URL == url {
+			atomic.StoreInt32(&u.drained, 1)
+			u.setHealthy(false)
+			return true
+		}
+	}
+	return false
+}
+
+func (p *ServiceProxy) Upstreams() []*Upstream {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	out := make([]*Upstream, len(p.upstreams))
+	copy(out, p.upstreams)
+	return out
+}
+
+// Pick selects a healthy upstream using weighted round robin: upstreams with
+// a higher Weight are chosen proportionally more often.
+func (p *ServiceProxy) Pick() (*Upstream, error) {
+	upstreams := p.Upstreams()
+
+	var healthy []*Upstream
+	totalWeight := 0
+	for _, u := range upstreams {
+		if !u.Healthy() {
+			continue
+		}
+		healthy = append(healthy, u)
+		totalWeight += upstreamWeight(u)
+	}
+
+	if len(healthy) == 0 {
+		return nil, fmt.Errorf("no healthy upstreams for service %q", p.Name)
+	}
+
+	n := atomic.AddUint64(&p.rrCounter, 1)
+	target := int(n % uint64(totalWeight))
+	for _, u := range healthy {
+		weight := upstreamWeight(u)
+		if target < weight {
+			return u, nil
+		}
+		target -= weight
+	}
+
+	return healthy[0], nil
+}
+
+func upstreamWeight(u *Upstream) int {
+	if u.Weight <= 0 {
+		return 1
+	}
+	return u.Weight
+}
+
+// ServiceRegistry holds every registered ServiceProxy plus the route table
+// that binds inbound requests to them, replacing the gateway's old
+// single-service, single-upstream setup.
+type ServiceRegistry struct {
+	mu       sync.RWMutex
+	services map[string]*ServiceProxy
+	routes   []RouteRule
+}
+
+func NewServiceRegistry() *ServiceRegistry {
+	return &ServiceRegistry{services: make(map[string]*ServiceProxy)}
+}
+
+func (r *ServiceRegistry) Register(p *ServiceProxy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.services[p.Name] = p
+}
+
+func (r *ServiceRegistry) Get(name string) (*ServiceProxy, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.services[name]
+	return p, ok
+}
+
+func (r *ServiceRegistry) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.services, name)
+}
+
+func (r *ServiceRegistry) List() []*ServiceProxy {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]*ServiceProxy, 0, len(r.services))
+	for _, p := range r.services {
+		out = append(out, p)
+	}
+	return out
+}
+
+func (r *ServiceRegistry) SetRoutes(routes []RouteRule) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.routes = routes
+}
+
+// ServiceForPath returns the service bound to path+method by the
+// longest-matching route rule, mirroring how RouteRoles and
+// RateLimitPolicies resolve path prefixes elsewhere in the gateway.
+func (r *ServiceRegistry) ServiceForPath(method, path string) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	best := ""
+	bestLen := -1
+	for _, rule := range r.routes {
+		if !strings.HasPrefix(path, rule.PathPrefix) || !methodAllowed(rule.Methods, method) {
+			continue
+		}
+		if len(rule.PathPrefix) > bestLen {
+			best = rule.Service
+			bestLen = len(rule.PathPrefix)
+		}
+	}
+	if bestLen < 0 {
+		return "", false
+	}
+	return best, true
+}
+
+func methodAllowed(methods []string, method string) bool {
+	if len(methods) == 0 {
+		return true
+	}
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// StartHealthChecks periodically probes every registered upstream's
+// HealthCheckPath and updates its healthy flag, so Pick only ever returns
+// live instances.
+func (r *ServiceRegistry) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.checkAll()
+			}
+		}
+	}()
+}
+
+func (r *ServiceRegistry) checkAll() {
+	for _, svc := range r.List() {
+		for _, u := range svc.Upstreams() {
+			if atomic.LoadInt32(&u.drained) == 1 {
+				continue
+			}
+			u.setHealthy(probeUpstream(svc.Client, u.URL+svc.HealthCheckPath))
+		}
+	}
+}
+
+func probeUpstream(client *http.Client, url string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// TLSConfig configures the gateway's own inbound HTTPS listener, including
+// optional mTLS client authentication for admin routes.
+type TLSConfig struct {
+	CertFile string `json:"certFile" yaml:"certFile"`
+	KeyFile  string `json:"keyFile" yaml:"keyFile"`
+
+	ClientCAFile string `json:"clientCaFile,omitempty" yaml:"clientCaFile,omitempty"`
+	// ClientAuthMode is one of "none", "request", "require", mapping to
+	// tls.NoClientCert, tls.RequestClientCert, tls.RequireAndVerifyClientCert.
+	ClientAuthMode   string   `json:"clientAuthMode" yaml:"clientAuthMode"`
+	AllowedClientCNs []string `json:"allowedClientCns,omitempty" yaml:"allowedClientCns,omitempty"`
+}
+
+func (t *TLSConfig) clientAuthType() tls.ClientAuthType {
+	switch t.ClientAuthMode {
+	case "require":
+		return tls.RequireAndVerifyClientCert
+	case "request":
+		return tls.RequestClientCert
+	default:
+		return tls.NoClientCert
+	}
+}
+
+// buildServerTLSConfig builds the *tls.Config for the gateway's listener.
+func (t *TLSConfig) buildServerTLSConfig() (*tls.Config, error) {
+	authType := t.clientAuthType()
+
+	tlsConfig := &tls.Config{
+		ClientAuth: authType,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if authType != tls.NoClientCert && t.ClientCAFile != "" {
+		pool, err := loadCertPool(t.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("client CA bundle: %w", err)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// ServiceTLSConfig configures mTLS for calls to one backend service's
+// upstreams: a CA bundle to trust, a client certificate to present, and an
+// optional pinned hostname/SAN.
+type ServiceTLSConfig struct {
+	CAFile         string `json:"caFile,omitempty" yaml:"caFile,omitempty"`
+	ClientCertFile string `json:"clientCertFile,omitempty" yaml:"clientCertFile,omitempty"`
+	ClientKeyFile  string `json:"clientKeyFile,omitempty" yaml:"clientKeyFile,omitempty"`
+	ServerName     string `json:"serverName,omitempty" yaml:"serverName,omitempty"`
+}
+
+// buildClientTLSConfig builds the *tls.Config a ServiceProxy's HTTP client
+// uses to authenticate to its upstreams.
+func (t *ServiceTLSConfig) buildClientTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if t.ServerName != "" {
+		tlsConfig.ServerName = t.ServerName
+	}
+
+	if t.CAFile != "" {
+		pool, err := loadCertPool(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("upstream CA bundle: %w", err)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if t.ClientCertFile != "" && t.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.ClientCertFile, t.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("upstream client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func loadCertPool(pemFile string) (*x509.CertPool, error) {
+	pemBytes, err := os.ReadFile(pemFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemBytes) {
+		return nil, fmt.Errorf("no certificates found in %s", pemFile)
+	}
+	return pool, nil
+}
+
+// clientCertCN authorizes the request's client certificate against
+// allowedCNs (checked against the CN and any DNS SANs). On a match it returns
+// the matching name, which may be a SAN, and true; otherwise the
+// certificate's CN and false.
+func clientCertCN(r *http.Request, allowedCNs []string) (string, bool) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return "", false
+	}
+
+	cert := r.TLS.PeerCertificates[0]
+	candidates := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+
+	for _, candidate := range candidates {
+		for _, allowed := range allowedCNs {
+			if candidate == allowed {
+				return candidate, true
+			}
+		}
+	}
+
+	return cert.Subject.CommonName, false
+}